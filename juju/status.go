@@ -1,8 +1,17 @@
 package main
 
 import (
+	"bytes"
 	"fmt"
+	"net"
+	"os"
+	"os/signal"
+	"path"
+	"sort"
 	"strconv"
+	"strings"
+	"text/tabwriter"
+	"time"
 
 	"launchpad.net/gnuflag"
 	"launchpad.net/juju-core/cmd"
@@ -12,28 +21,38 @@ import (
 )
 
 type StatusCommand struct {
-	EnvName string
-	out     cmd.Output
+	EnvName  string
+	out      cmd.Output
+	patterns []string
+	watch    time.Duration
+	follow   bool
+	timeout  time.Duration
 }
 
 var statusDoc = "This command will report on the runtime state of various system entities."
 
 func (c *StatusCommand) Info() *cmd.Info {
 	return &cmd.Info{
-		"status", "", "Output status information about an environment.", statusDoc,
+		"status", "[pattern ...]", "Output status information about an environment.", statusDoc,
 	}
 }
 
 func (c *StatusCommand) Init(f *gnuflag.FlagSet, args []string) error {
 	addEnvironFlags(&c.EnvName, f)
-	c.out.AddFlags(f, "yaml", map[string]cmd.Formatter{
-		"yaml": cmd.FormatYaml,
-		"json": cmd.FormatJson,
+	c.out.AddFlags(f, "tabular", map[string]cmd.Formatter{
+		"yaml":    cmd.FormatYaml,
+		"json":    cmd.FormatJson,
+		"tabular": formatTabular,
 	})
+	f.DurationVar(&c.watch, "watch", 0, "re-print status every `interval` as the environment changes")
+	f.DurationVar(&c.watch, "w", 0, "")
+	f.BoolVar(&c.follow, "follow", false, "stream a fresh status document whenever the environment changes")
+	f.DurationVar(&c.timeout, "timeout", 0, "stop watching after `duration` (0 means run until interrupted)")
 	if err := f.Parse(true, args); err != nil {
 		return err
 	}
-	return cmd.CheckEmpty(f.Args())
+	c.patterns = f.Args()
+	return nil
 }
 
 func (c *StatusCommand) Run(ctx *cmd.Context) error {
@@ -43,44 +62,345 @@ func (c *StatusCommand) Run(ctx *cmd.Context) error {
 	}
 	defer conn.Close()
 
-	instances, err := fetchAllInstances(conn.Environ)
+	st, err := conn.State()
 	if err != nil {
 		return err
 	}
 
-	state, err := conn.State()
+	result, err := c.buildStatus(conn, st)
 	if err != nil {
 		return err
 	}
 
-	machines, err := fetchAllMachines(state)
+	if c.watch > 0 || c.follow {
+		return c.watchLoop(ctx, conn, st, result)
+	}
+	return c.writeStatus(ctx, result)
+}
+
+// buildStatus fetches the current machines and services from st, applies
+// any status patterns, and assembles the in-memory status model.
+func (c *StatusCommand) buildStatus(conn *juju.Conn, st *state.State) (statusResult, error) {
+	instances, err := fetchAllInstances(conn.Environ)
 	if err != nil {
-		return err
+		return statusResult{}, err
 	}
 
-	services, err := fetchAllServices(state)
+	machines, err := fetchAllMachines(st)
 	if err != nil {
-		return err
+		return statusResult{}, err
+	}
+
+	services, err := fetchAllServices(st)
+	if err != nil {
+		return statusResult{}, err
 	}
 
-	result := make(map[string]interface{})
+	if len(c.patterns) > 0 {
+		machines, services, err = filterStatus(machines, services, instances, c.patterns)
+		if err != nil {
+			return statusResult{}, err
+		}
+	}
 
-	result["machines"], err = processMachines(machines, instances)
+	var result statusResult
+	result.Machines, err = processMachines(machines, instances)
 	if err != nil {
-		return err
+		return statusResult{}, err
 	}
 
-	result["services"], err = processServices(services)
+	result.Services, err = processServices(services, result.Machines)
 	if err != nil {
-		return err
+		return statusResult{}, err
 	}
+	return result, nil
+}
 
+// writeStatus renders a single status document using the configured
+// formatter.
+func (c *StatusCommand) writeStatus(ctx *cmd.Context, result statusResult) error {
 	if c.out.Name() == "json" {
 		return c.out.Write(ctx, jsonify(result))
 	}
 	return c.out.Write(ctx, result)
 }
 
+// watchLoop re-renders the status document whenever the environment's
+// machines, services or units change, until interrupted or c.timeout
+// elapses. model is the already-built initial frame (built once by Run, not
+// rebuilt here). Deltas are read from a state.AllWatcher-style change
+// stream; on each batch only the affected machines and services are
+// refetched and patched into the running model, rather than re-running
+// buildStatus in full. If c.watch is set, the frame is additionally
+// re-rendered on every tick of that interval, so a static environment still
+// refreshes as the --watch flag's help text promises.
+func (c *StatusCommand) watchLoop(ctx *cmd.Context, conn *juju.Conn, st *state.State, model statusResult) error {
+	watcher := st.WatchAll()
+	defer watcher.Stop()
+
+	sigc := make(chan os.Signal, 1)
+	signal.Notify(sigc, os.Interrupt)
+	defer signal.Stop(sigc)
+
+	var deadline <-chan time.Time
+	if c.timeout > 0 {
+		deadline = time.After(c.timeout)
+	}
+
+	var ticker <-chan time.Time
+	if c.watch > 0 {
+		t := time.NewTicker(c.watch)
+		defer t.Stop()
+		ticker = t.C
+	}
+
+	patterns := compilePatterns(c.patterns)
+
+	if err := c.writeFrame(ctx, model); err != nil {
+		return err
+	}
+
+	changes := make(chan []state.Delta)
+	errs := make(chan error, 1)
+	go func() {
+		for {
+			deltas, err := watcher.Next()
+			if err != nil {
+				errs <- err
+				return
+			}
+			changes <- deltas
+		}
+	}()
+
+	for {
+		select {
+		case <-sigc:
+			return c.writeFrame(ctx, model)
+		case <-deadline:
+			return nil
+		case err := <-errs:
+			return err
+		case <-ticker:
+			if err := c.writeFrame(ctx, model); err != nil {
+				return err
+			}
+		case deltas := <-changes:
+			if err := applyDeltas(conn, st, &model, deltas, patterns); err != nil {
+				return err
+			}
+			if c.follow || c.watch > 0 {
+				if err := c.writeFrame(ctx, model); err != nil {
+					return err
+				}
+			}
+		}
+	}
+}
+
+// writeFrame writes a single frame of a watched status stream: documents
+// are separated by "---" for yaml, are newline-delimited for json, and
+// clear the screen before each redraw for tabular.
+func (c *StatusCommand) writeFrame(ctx *cmd.Context, result statusResult) error {
+	switch c.out.Name() {
+	case "tabular":
+		fmt.Fprint(ctx.Stdout, "\033[2J\033[H")
+	case "yaml":
+		fmt.Fprintln(ctx.Stdout, "---")
+	}
+	return c.writeStatus(ctx, result)
+}
+
+// applyDeltas patches model in place with the machines and services named
+// in deltas, refetching only those entities from state rather than
+// rebuilding the whole model; the environ's instance list is still fetched
+// in full on each call. When patterns is non-empty, each delta target is re-matched
+// against it before being inserted or updated, and is pruned from model if
+// it no longer matches -- keeping a --watch/--follow stream honouring the
+// same status patterns the initial frame was filtered by.
+func applyDeltas(conn *juju.Conn, st *state.State, model *statusResult, deltas []state.Delta, patterns []statusPattern) error {
+	instances, err := fetchAllInstances(conn.Environ)
+	if err != nil {
+		return err
+	}
+	for _, d := range deltas {
+		switch d.Kind {
+		case "machine":
+			id, err := strconv.Atoi(d.Id)
+			if err != nil {
+				return err
+			}
+			if d.Removed {
+				delete(model.Machines, id)
+				continue
+			}
+			m, err := st.Machine(d.Id)
+			if err != nil {
+				return err
+			}
+			var instance environs.Instance
+			instid, err := m.InstanceId()
+			if _, ok := err.(*state.NotFoundError); ok {
+				// Not yet provisioned.
+			} else if err != nil {
+				return err
+			} else {
+				inst, ok := instances[instid]
+				if !ok {
+					return fmt.Errorf("instance %s for machine %s not found", instid, d.Id)
+				}
+				instance = inst
+			}
+			if len(patterns) > 0 {
+				matched, err := matchMachine(patterns, m, instance)
+				if err != nil {
+					return err
+				}
+				if !matched {
+					delete(model.Machines, id)
+					continue
+				}
+			}
+			if instance == nil {
+				model.Machines[id] = MachineStatus{InstanceId: "pending"}
+				continue
+			}
+			status, err := processMachine(m, instance)
+			if err != nil {
+				return err
+			}
+			model.Machines[id] = status
+		case "service":
+			if d.Removed {
+				delete(model.Services, d.Id)
+				continue
+			}
+			s, err := st.Service(d.Id)
+			if err != nil {
+				return err
+			}
+			if len(patterns) > 0 {
+				matched, err := matchService(patterns, s)
+				if err != nil {
+					return err
+				}
+				if !matched {
+					delete(model.Services, d.Id)
+					continue
+				}
+			}
+			status, err := processService(s, model.Machines)
+			if err != nil {
+				return err
+			}
+			model.Services[d.Id] = status
+		case "unit":
+			if d.Removed {
+				svcName := unitServiceName(d.Id)
+				svc, err := st.Service(svcName)
+				if _, ok := err.(*state.NotFoundError); ok {
+					delete(model.Services, svcName)
+					continue
+				} else if err != nil {
+					return err
+				}
+				if len(patterns) > 0 {
+					matched, err := matchService(patterns, svc)
+					if err != nil {
+						return err
+					}
+					if !matched {
+						delete(model.Services, svcName)
+						continue
+					}
+				}
+				if s, ok := model.Services[svcName]; ok {
+					delete(s.Units, d.Id)
+					model.Services[svcName] = s
+				}
+				continue
+			}
+			u, err := st.Unit(d.Id)
+			if err != nil {
+				return err
+			}
+			svc, err := st.Service(u.ServiceName())
+			if err != nil {
+				return err
+			}
+			if len(patterns) > 0 {
+				matched, err := matchService(patterns, svc)
+				if err != nil {
+					return err
+				}
+				if !matched {
+					delete(model.Services, svc.Name())
+					continue
+				}
+			}
+			status, err := processService(svc, model.Machines)
+			if err != nil {
+				return err
+			}
+			model.Services[svc.Name()] = status
+		}
+	}
+	return nil
+}
+
+// unitServiceName returns the service name embedded in a unit name of the
+// form "service/0".
+func unitServiceName(unitName string) string {
+	if i := strings.LastIndex(unitName, "/"); i >= 0 {
+		return unitName[:i]
+	}
+	return unitName
+}
+
+// statusResult is the in-memory model rendered by all of the status
+// formatters (yaml, json and tabular).
+type statusResult struct {
+	Machines map[int]MachineStatus    `json:"-" yaml:"machines"`
+	Services map[string]ServiceStatus `json:"-" yaml:"services"`
+}
+
+// MachineStatus holds the status of a single machine, as reported by
+// "juju status".
+type MachineStatus struct {
+	AgentState     string          `json:"agent-state,omitempty" yaml:"agent-state,omitempty"`
+	AgentStateInfo string          `json:"agent-state-info,omitempty" yaml:"agent-state-info,omitempty"`
+	AgentVersion   string          `json:"agent-version,omitempty" yaml:"agent-version,omitempty"`
+	DNSName        string          `json:"dns-name,omitempty" yaml:"dns-name,omitempty"`
+	InstanceId     string          `json:"instance-id" yaml:"instance-id"`
+	Hardware       string          `json:"hardware,omitempty" yaml:"hardware,omitempty"`
+	Addresses      []AddressStatus `json:"addresses,omitempty" yaml:"addresses,omitempty"`
+}
+
+// AddressStatus holds a single network address reported against a machine.
+type AddressStatus struct {
+	Value string `json:"value" yaml:"value"`
+	Type  string `json:"type" yaml:"type"`
+	Scope string `json:"scope" yaml:"scope"`
+}
+
+// ServiceStatus holds the status of a single service, as reported by
+// "juju status".
+type ServiceStatus struct {
+	Charm     string                `json:"charm" yaml:"charm"`
+	Exposed   bool                  `json:"exposed" yaml:"exposed"`
+	Units     map[string]UnitStatus `json:"units,omitempty" yaml:"units,omitempty"`
+	Relations map[string][]string   `json:"relations,omitempty" yaml:"relations,omitempty"`
+}
+
+// UnitStatus holds the status of a single unit, as reported by
+// "juju status".
+type UnitStatus struct {
+	AgentState     string `json:"agent-state,omitempty" yaml:"agent-state,omitempty"`
+	AgentStateInfo string `json:"agent-state-info,omitempty" yaml:"agent-state-info,omitempty"`
+	Machine        string `json:"machine,omitempty" yaml:"machine,omitempty"`
+	PublicAddress  string `json:"public-address,omitempty" yaml:"public-address,omitempty"`
+}
+
 // fetchAllInstances returns a map[string]environs.Instance representing
 // a mapping of instance ids to their respective instance.
 func fetchAllInstances(env environs.Environ) (map[string]environs.Instance, error) {
@@ -109,7 +429,7 @@ func fetchAllMachines(st *state.State) (map[int]*state.Machine, error) {
 	return v, nil
 }
 
-// fetchAllServices returns a map representing a mapping of service 
+// fetchAllServices returns a map representing a mapping of service
 // names to services.
 func fetchAllServices(st *state.State) (map[string]*state.Service, error) {
 	v := make(map[string]*state.Service)
@@ -124,13 +444,13 @@ func fetchAllServices(st *state.State) (map[string]*state.Service, error) {
 }
 
 // processMachines gathers information about machines.
-func processMachines(machines map[int]*state.Machine, instances map[string]environs.Instance) (map[int]interface{}, error) {
-	r := make(map[int]interface{})
+func processMachines(machines map[int]*state.Machine, instances map[string]environs.Instance) (map[int]MachineStatus, error) {
+	r := make(map[int]MachineStatus)
 	for _, m := range machines {
 		instid, err := m.InstanceId()
-		if err, ok := err.(*state.NotFoundError); ok {
-			r[m.Id()] = map[string]interface{}{
-				"instance-id": "pending",
+		if _, ok := err.(*state.NotFoundError); ok {
+			r[m.Id()] = MachineStatus{
+				InstanceId: "pending",
 			}
 		} else if err != nil {
 			return nil, err
@@ -138,7 +458,7 @@ func processMachines(machines map[int]*state.Machine, instances map[string]envir
 			instance, ok := instances[instid]
 			if !ok {
 				// Double plus ungood. There is an instance id recorded for this machine in the state,
-				// yet the environ cannot find that id. 
+				// yet the environ cannot find that id.
 				return nil, fmt.Errorf("instance %s for machine %d not found", instid, m.Id())
 			}
 			machine, err := processMachine(m, instance)
@@ -151,35 +471,92 @@ func processMachines(machines map[int]*state.Machine, instances map[string]envir
 	return r, nil
 }
 
-func processMachine(machine *state.Machine, instance environs.Instance) (map[string]interface{}, error) {
-	r := make(map[string]interface{})
+// processMachine gathers information about a single machine.
+//
+// machine.HardwareCharacteristics and machine.Addresses read state that is
+// defined and populated by the provisioner in launchpad.net/juju-core/state
+// and launchpad.net/juju-core/instance; this package only consumes it.
+func processMachine(machine *state.Machine, instance environs.Instance) (MachineStatus, error) {
+	var r MachineStatus
 	dnsname, err := instance.DNSName()
 	if err != nil {
-		return nil, err
+		return MachineStatus{}, err
+	}
+	r.DNSName = dnsname
+	r.InstanceId = instance.Id()
+
+	tools, err := machine.AgentTools()
+	if _, ok := err.(*state.NotFoundError); ok {
+		// No tools recorded yet; leave agent-version blank.
+	} else if err != nil {
+		return MachineStatus{}, err
+	} else {
+		r.AgentVersion = tools.Binary.Number.String()
+	}
+
+	hc, err := machine.HardwareCharacteristics()
+	if _, ok := err.(*state.NotFoundError); ok {
+		// Not provisioned with hardware info yet.
+	} else if err != nil {
+		return MachineStatus{}, err
+	} else if hc != nil {
+		r.Hardware = hc.String()
+	}
+
+	for _, addr := range machine.Addresses() {
+		r.Addresses = append(r.Addresses, AddressStatus{
+			Value: addr.Value,
+			Type:  string(addr.Type),
+			Scope: string(addr.NetworkScope),
+		})
 	}
-	r["dns-name"] = dnsname
-	r["instance-id"] = instance.Id()
 
 	alive, err := machine.AgentAlive()
 	if err != nil {
-		return nil, err
+		return MachineStatus{}, err
+	}
+	status, info, err := machine.Status()
+	if err != nil {
+		return MachineStatus{}, err
 	}
+	r.AgentState, r.AgentStateInfo = agentStatusAndInfo(string(status), info, alive)
+	return r, nil
+}
 
-	// TODO(dfc) revisit this once unit-status is done
+// agentStatusAndInfo derives the reported agent-state and agent-state-info
+// for a machine or unit from its last reported status and whether its agent
+// is currently pinging presence. A dead agent is always reported as "down",
+// with its last known status (and any accompanying info) stashed into
+// agent-state-info.
+func agentStatusAndInfo(status, info string, alive bool) (string, string) {
 	if alive {
-		r["agent-state"] = "running"
+		return status, info
 	}
+	if info == "" {
+		return "down", status
+	}
+	return "down", status + ": " + info
+}
 
-	// TODO(dfc) unit-status
-	return r, nil
+// publicAddress picks the best address to report as a unit's
+// public-address: an address explicitly scoped "public", falling back to
+// the machine's instance DNS name if none is recorded.
+func publicAddress(m MachineStatus) string {
+	for _, addr := range m.Addresses {
+		if addr.Scope == "public" {
+			return addr.Value
+		}
+	}
+	return m.DNSName
 }
 
-// processServices gathers information about services.
-func processServices(services map[string]*state.Service) (map[string]interface{}, error) {
-	r := make(map[string]interface{})
+// processServices gathers information about services. machines is used to
+// resolve each unit's public address from its assigned machine.
+func processServices(services map[string]*state.Service, machines map[int]MachineStatus) (map[string]ServiceStatus, error) {
+	r := make(map[string]ServiceStatus)
 	for _, s := range services {
 		var err error
-		r[s.Name()], err = processService(s)
+		r[s.Name()], err = processService(s, machines)
 		if err != nil {
 			return nil, err
 		}
@@ -187,30 +564,416 @@ func processServices(services map[string]*state.Service) (map[string]interface{}
 	return r, nil
 }
 
-func processService(service *state.Service) (map[string]interface{}, error) {
-	r := make(map[string]interface{})
+func processService(service *state.Service, machines map[int]MachineStatus) (ServiceStatus, error) {
+	var r ServiceStatus
 	ch, err := service.Charm()
 	if err != nil {
-		return nil, err
+		return ServiceStatus{}, err
 	}
-	r["charm"] = ch.Meta().Name
-	r["exposed"], err = service.IsExposed()
+	r.Charm = ch.URL().String()
+	r.Exposed, err = service.IsExposed()
 	if err != nil {
-		return nil, err
+		return ServiceStatus{}, err
+	}
+
+	units, err := service.AllUnits()
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+	if len(units) > 0 {
+		r.Units = make(map[string]UnitStatus)
+		for _, u := range units {
+			unit, err := processUnit(u, machines)
+			if err != nil {
+				return ServiceStatus{}, err
+			}
+			r.Units[u.Name()] = unit
+		}
+	}
+
+	relations, err := service.Relations()
+	if err != nil {
+		return ServiceStatus{}, err
+	}
+	if len(relations) > 0 {
+		r.Relations, err = processRelations(service, relations)
+		if err != nil {
+			return ServiceStatus{}, err
+		}
 	}
-	// TODO(dfc) process units and relations
 	return r, nil
 }
 
+// processUnit gathers information about a single unit. machines is used to
+// resolve the unit's public address from its assigned machine.
+func processUnit(unit *state.Unit, machines map[int]MachineStatus) (UnitStatus, error) {
+	var r UnitStatus
+	machineId, err := unit.AssignedMachineId()
+	if err != nil && !state.IsNotAssigned(err) {
+		return UnitStatus{}, err
+	}
+	r.Machine = machineId
+	if id, err := strconv.Atoi(machineId); err == nil {
+		if m, ok := machines[id]; ok {
+			r.PublicAddress = publicAddress(m)
+		}
+	}
+
+	status, info, err := unit.Status()
+	if err != nil {
+		return UnitStatus{}, err
+	}
+	alive, err := unit.AgentAlive()
+	if err != nil {
+		return UnitStatus{}, err
+	}
+	r.AgentState, r.AgentStateInfo = agentStatusAndInfo(string(status), info, alive)
+	return r, nil
+}
+
+// processRelations gathers the names of the services related to service
+// through each of its relations, keyed by the local endpoint's relation
+// name.
+func processRelations(service *state.Service, relations []*state.Relation) (map[string][]string, error) {
+	r := make(map[string][]string)
+	for _, rel := range relations {
+		eps, err := rel.Endpoints()
+		if err != nil {
+			return nil, err
+		}
+		var relationName string
+		var related []string
+		for _, ep := range eps {
+			if ep.ServiceName == service.Name() {
+				relationName = ep.RelationName
+			} else {
+				related = append(related, ep.ServiceName)
+			}
+		}
+		if relationName == "" {
+			// None of the endpoints belongs to service; use the first
+			// endpoint's name.
+			relationName = eps[0].RelationName
+		}
+		r[relationName] = append(r[relationName], related...)
+	}
+	return r, nil
+}
+
+// agentStatusKeywords are the reserved words that a status pattern may use
+// to match against a machine or unit's agent-state, rather than its name.
+var agentStatusKeywords = map[string]bool{
+	"pending": true,
+	"started": true,
+	"error":   true,
+	"down":    true,
+}
+
+// compilePatterns turns the raw, positional pattern arguments passed to
+// juju status into matchable statusPattern values.
+func compilePatterns(rawPatterns []string) []statusPattern {
+	patterns := make([]statusPattern, len(rawPatterns))
+	for i, raw := range rawPatterns {
+		patterns[i] = statusPattern{raw}
+	}
+	return patterns
+}
+
+// statusPattern is a single positional argument passed to juju status. It is
+// matched against machine ids, dns names/addresses, agent status and unit
+// and service names using glob syntax, CIDR notation or the reserved
+// agent-state keywords above.
+type statusPattern struct {
+	raw string
+}
+
+// matchString reports whether s matches the pattern using shell glob syntax.
+func (p statusPattern) matchString(s string) bool {
+	ok, err := path.Match(p.raw, s)
+	return err == nil && ok
+}
+
+// matchAddress reports whether addr falls inside the pattern, when the
+// pattern is a CIDR, or matches it verbatim otherwise.
+func (p statusPattern) matchAddress(addr string) bool {
+	if _, ipnet, err := net.ParseCIDR(p.raw); err == nil {
+		if ip := net.ParseIP(addr); ip != nil {
+			return ipnet.Contains(ip)
+		}
+		return false
+	}
+	return p.matchString(addr)
+}
+
+// matchAgentState reports whether the pattern is one of the reserved
+// keywords and, if so, whether it matches state, which is "down" for a dead
+// agent or agent-state otherwise.
+func (p statusPattern) matchAgentState(state string) bool {
+	return agentStatusKeywords[p.raw] && p.raw == state
+}
+
+// matchMachine reports whether any pattern matches machine m: its id, its
+// dns name or addresses, or its agent status. instance may be nil if m has
+// not yet been provisioned, in which case only its id and agent-state (e.g.
+// "pending") can match.
+func matchMachine(patterns []statusPattern, m *state.Machine, instance environs.Instance) (bool, error) {
+	id := strconv.Itoa(m.Id())
+	var dnsname string
+	if instance != nil {
+		name, err := instance.DNSName()
+		if err != nil && err != state.ErrNoInstanceId {
+			return false, err
+		}
+		dnsname = name
+	}
+	alive, err := m.AgentAlive()
+	if err != nil {
+		return false, err
+	}
+	status, info, err := m.Status()
+	if err != nil {
+		return false, err
+	}
+	agentState, _ := agentStatusAndInfo(string(status), info, alive)
+	if instance == nil {
+		// An unprovisioned machine is reported with instance-id "pending".
+		agentState = "pending"
+	}
+	for _, p := range patterns {
+		if p.matchString(id) || p.matchAddress(dnsname) || p.matchAgentState(agentState) {
+			return true, nil
+		}
+		for _, addr := range m.Addresses() {
+			if p.matchAddress(addr.Value) {
+				return true, nil
+			}
+		}
+	}
+	return false, nil
+}
+
+// matchUnit reports whether any pattern matches unit u: its name, its
+// service, its assigned machine id, or its agent status.
+func matchUnit(patterns []statusPattern, u *state.Unit) (bool, error) {
+	alive, err := u.AgentAlive()
+	if err != nil {
+		return false, err
+	}
+	status, _, err := u.Status()
+	if err != nil {
+		return false, err
+	}
+	agentState := string(status)
+	if !alive {
+		agentState = "down"
+	}
+	machineId, err := u.AssignedMachineId()
+	if err != nil && !state.IsNotAssigned(err) {
+		return false, err
+	}
+	for _, p := range patterns {
+		if p.matchString(u.Name()) || p.matchString(u.ServiceName()) ||
+			p.matchString(machineId) || p.matchAgentState(agentState) {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// matchService reports whether any pattern matches service s directly by
+// name, or matches one of its units per matchUnit.
+func matchService(patterns []statusPattern, s *state.Service) (bool, error) {
+	for _, p := range patterns {
+		if p.matchString(s.Name()) {
+			return true, nil
+		}
+	}
+	units, err := s.AllUnits()
+	if err != nil {
+		return false, err
+	}
+	for _, u := range units {
+		ok, err := matchUnit(patterns, u)
+		if err != nil {
+			return false, err
+		}
+		if ok {
+			return true, nil
+		}
+	}
+	return false, nil
+}
+
+// filterStatus restricts machines and services to those matched by patterns,
+// along with the services and machines of any matched unit.
+func filterStatus(
+	machines map[int]*state.Machine,
+	services map[string]*state.Service,
+	instances map[string]environs.Instance,
+	rawPatterns []string,
+) (map[int]*state.Machine, map[string]*state.Service, error) {
+	patterns := compilePatterns(rawPatterns)
+
+	matchedMachines := make(map[int]bool)
+	matchedServices := make(map[string]*state.Service)
+
+	for _, svc := range services {
+		svcMatched := false
+		units, err := svc.AllUnits()
+		if err != nil {
+			return nil, nil, err
+		}
+
+		nameMatched := false
+		for _, p := range patterns {
+			if p.matchString(svc.Name()) {
+				nameMatched = true
+				break
+			}
+		}
+
+		for _, u := range units {
+			matched := nameMatched
+			if !matched {
+				matched, err = matchUnit(patterns, u)
+				if err != nil {
+					return nil, nil, err
+				}
+			}
+			if !matched {
+				continue
+			}
+			svcMatched = true
+			if machineId, err := u.AssignedMachineId(); err == nil {
+				if id, err := strconv.Atoi(machineId); err == nil {
+					matchedMachines[id] = true
+				}
+			} else if !state.IsNotAssigned(err) {
+				return nil, nil, err
+			}
+		}
+		if nameMatched {
+			svcMatched = true
+		}
+		if svcMatched {
+			matchedServices[svc.Name()] = svc
+		}
+	}
+
+	for id, m := range machines {
+		if matchedMachines[id] {
+			continue
+		}
+		var instance environs.Instance
+		instid, err := m.InstanceId()
+		if _, ok := err.(*state.NotFoundError); ok {
+			// Not yet provisioned; still eligible to match on id or an
+			// agent-state keyword such as "pending".
+		} else if err != nil {
+			return nil, nil, err
+		} else {
+			inst, ok := instances[instid]
+			if !ok {
+				return nil, nil, fmt.Errorf("instance %s for machine %d not found", instid, m.Id())
+			}
+			instance = inst
+		}
+		ok, err := matchMachine(patterns, m, instance)
+		if err != nil {
+			return nil, nil, err
+		}
+		if ok {
+			matchedMachines[id] = true
+		}
+	}
+
+	filteredMachines := make(map[int]*state.Machine)
+	for id := range matchedMachines {
+		if m, ok := machines[id]; ok {
+			filteredMachines[id] = m
+		}
+	}
+	return filteredMachines, matchedServices, nil
+}
+
 // jsonify converts the keys of the machines map into their string
 // equivalents for compatibility with encoding/json.
-func jsonify(r map[string]interface{}) map[string]map[string]interface{} {
-	m := map[string]map[string]interface{}{
-		"services": r["services"].(map[string]interface{}),
-		"machines": make(map[string]interface{}),
+func jsonify(r statusResult) map[string]interface{} {
+	machines := make(map[string]MachineStatus, len(r.Machines))
+	for k, v := range r.Machines {
+		machines[strconv.Itoa(k)] = v
+	}
+	return map[string]interface{}{
+		"machines": machines,
+		"services": r.Services,
+	}
+}
+
+// formatTabular renders a statusResult as aligned, human readable tables:
+// one for machines, one for services and one for units.
+func formatTabular(value interface{}) ([]byte, error) {
+	result, ok := value.(statusResult)
+	if !ok {
+		return nil, fmt.Errorf("expected value of type %T, got %T", result, value)
+	}
+	var out bytes.Buffer
+	tw := tabwriter.NewWriter(&out, 0, 2, 2, ' ', 0)
+
+	fmt.Fprintln(tw, "[Machines]")
+	fmt.Fprintln(tw, "ID\tSTATE\tDNS-NAME\tINSTANCE-ID\tVERSION")
+	for _, id := range sortedMachineIds(result.Machines) {
+		m := result.Machines[id]
+		fmt.Fprintf(tw, "%d\t%s\t%s\t%s\t%s\n", id, m.AgentState, m.DNSName, m.InstanceId, m.AgentVersion)
+	}
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "[Services]")
+	fmt.Fprintln(tw, "NAME\tEXPOSED\tCHARM")
+	for _, name := range sortedServiceNames(result.Services) {
+		s := result.Services[name]
+		fmt.Fprintf(tw, "%s\t%v\t%s\n", name, s.Exposed, s.Charm)
 	}
-	for k, v := range r["machines"].(map[int]interface{}) {
-		m["machines"][strconv.Itoa(k)] = v
+	fmt.Fprintln(tw)
+
+	fmt.Fprintln(tw, "[Units]")
+	fmt.Fprintln(tw, "UNIT\tSTATE\tMACHINE\tPUBLIC-ADDRESS\tMESSAGE")
+	for _, name := range sortedServiceNames(result.Services) {
+		s := result.Services[name]
+		for _, unit := range sortedUnitNames(s.Units) {
+			u := s.Units[unit]
+			fmt.Fprintf(tw, "%s\t%s\t%s\t%s\t%s\n", unit, u.AgentState, u.Machine, u.PublicAddress, u.AgentStateInfo)
+		}
+	}
+
+	if err := tw.Flush(); err != nil {
+		return nil, err
+	}
+	return out.Bytes(), nil
+}
+
+func sortedMachineIds(machines map[int]MachineStatus) []int {
+	ids := make([]int, 0, len(machines))
+	for id := range machines {
+		ids = append(ids, id)
 	}
-	return m
-}
\ No newline at end of file
+	sort.Ints(ids)
+	return ids
+}
+
+func sortedServiceNames(services map[string]ServiceStatus) []string {
+	names := make([]string, 0, len(services))
+	for name := range services {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+func sortedUnitNames(units map[string]UnitStatus) []string {
+	names := make([]string, 0, len(units))
+	for name := range units {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
@@ -16,6 +16,8 @@ import (
 	coretesting "launchpad.net/juju-core/testing"
 	"launchpad.net/juju-core/version"
 	"net/url"
+	"os"
+	"strings"
 	"time"
 )
 
@@ -343,6 +345,97 @@ var statusTests = []testCase{
 				},
 			},
 		},
+	), test(
+		"pattern matching machines by agent-state keywords",
+		addMachine{"0", state.JobManageEnviron},
+		addMachine{"1", state.JobHostUnits},
+		startAliveMachine{"1"},
+		setMachineStatus{"1", params.MachineError, "Beware the red toys"},
+		expectPattern{
+			"pending keyword matches the unprovisioned bootstrap machine only",
+			[]string{"pending"},
+			M{
+				"machines": M{
+					"0": M{
+						"instance-id": "pending",
+					},
+				},
+				"services": M{},
+			},
+		},
+		expectPattern{
+			"error keyword matches the machine in error state, not the alive one",
+			[]string{"error"},
+			M{
+				"machines": M{
+					"1": M{
+						"dns-name":         "dummyenv-1.dns",
+						"instance-id":      "dummyenv-1",
+						"agent-state":      "error",
+						"agent-state-info": "Beware the red toys",
+					},
+				},
+				"services": M{},
+			},
+		},
+	), test(
+		"pattern matching services and units by glob and by name",
+		addMachine{"0", state.JobManageEnviron},
+		startAliveMachine{"0"},
+		setMachineStatus{"0", params.MachineStarted, ""},
+		addMachine{"1", state.JobHostUnits},
+		startAliveMachine{"1"},
+		setMachineStatus{"1", params.MachineStarted, ""},
+		addCharm{"dummy"},
+		addService{"dummy-service", "dummy"},
+		addUnit{"dummy-service", "1"},
+		setUnitStatus{"dummy-service/0", params.UnitStarted, ""},
+		expectPattern{
+			"a glob matching the unit name pulls in its machine",
+			[]string{"dummy-service/*"},
+			M{
+				"machines": M{
+					"1": machine1,
+				},
+				"services": M{
+					"dummy-service": M{
+						"charm":   "local:series/dummy-1",
+						"exposed": false,
+						"units": M{
+							"dummy-service/0": M{
+								"machine":          "1",
+								"agent-state":      "down",
+								"agent-state-info": "started",
+								"public-address":   "dummyenv-1.dns",
+							},
+						},
+					},
+				},
+			},
+		},
+		expectPattern{
+			"a pattern matching the service by name also pulls in its unit's machine",
+			[]string{"dummy-service"},
+			M{
+				"machines": M{
+					"1": machine1,
+				},
+				"services": M{
+					"dummy-service": M{
+						"charm":   "local:series/dummy-1",
+						"exposed": false,
+						"units": M{
+							"dummy-service/0": M{
+								"machine":          "1",
+								"agent-state":      "down",
+								"agent-state-info": "started",
+								"public-address":   "dummyenv-1.dns",
+							},
+						},
+					},
+				},
+			},
+		},
 	),
 }
 
@@ -461,6 +554,27 @@ func (au addUnit) step(c *C, ctx *context) {
 	c.Assert(err, IsNil)
 }
 
+// addRelation adds a relation between the given endpoints, each written as
+// "service:relation". A single endpoint denotes a peer relation.
+type addRelation struct {
+	endpoints []string
+}
+
+func (ar addRelation) step(c *C, ctx *context) {
+	eps := make([]state.Endpoint, len(ar.endpoints))
+	for i, e := range ar.endpoints {
+		parts := strings.SplitN(e, ":", 2)
+		c.Assert(parts, HasLen, 2)
+		svc, err := ctx.st.Service(parts[0])
+		c.Assert(err, IsNil)
+		ep, err := svc.Endpoint(parts[1])
+		c.Assert(err, IsNil)
+		eps[i] = ep
+	}
+	_, err := ctx.st.AddRelation(eps...)
+	c.Assert(err, IsNil)
+}
+
 type addAliveUnit struct {
 	serviceName string
 	machineId   string
@@ -541,6 +655,334 @@ func (e expect) step(c *C, ctx *context) {
 	}
 }
 
+// expectPattern is like expect, but runs status with the given patterns as
+// positional arguments, to exercise filtering.
+type expectPattern struct {
+	what     string
+	patterns []string
+	output   M
+}
+
+func (e expectPattern) step(c *C, ctx *context) {
+	c.Log("expect: %s", e.what)
+
+	for _, format := range statusFormats {
+		c.Logf("format %q", format.name)
+		args := append([]string{"--format", format.name}, e.patterns...)
+		code, stdout, stderr := runStatus(c, args...)
+		c.Assert(code, Equals, 0)
+		c.Assert(stderr, HasLen, 0)
+
+		buf, err := format.marshal(e.output)
+		c.Assert(err, IsNil)
+		expected := make(M)
+		err = format.unmarshal(buf, &expected)
+		c.Assert(err, IsNil)
+
+		actual := make(M)
+		err = format.unmarshal(stdout, &actual)
+		c.Assert(err, IsNil)
+		c.Assert(actual, DeepEquals, expected)
+	}
+}
+
+func (s *StatusSuite) TestFormatTabular(c *C) {
+	result := statusResult{
+		Machines: map[int]MachineStatus{
+			0: {
+				AgentState: "started",
+				DNSName:    "dummyenv-0.dns",
+				InstanceId: "dummyenv-0",
+			},
+		},
+		Services: map[string]ServiceStatus{
+			"dummy-service": {
+				Charm:   "local:series/dummy-1",
+				Exposed: false,
+				Units: map[string]UnitStatus{
+					"dummy-service/0": {
+						AgentState:    "started",
+						Machine:       "0",
+						PublicAddress: "dummyenv-0.dns",
+					},
+				},
+			},
+		},
+	}
+	out, err := formatTabular(result)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Matches, "(?s).*UNIT.*STATE.*MACHINE.*PUBLIC-ADDRESS.*MESSAGE.*")
+	c.Assert(string(out), Matches, "(?s).*dummy-service/0.*started.*0.*dummyenv-0.dns.*")
+}
+
+func (s *StatusSuite) TestStatusPatternMatching(c *C) {
+	// Glob syntax against unit/service names.
+	c.Assert(statusPattern{"mysql/*"}.matchString("mysql/0"), Equals, true)
+	c.Assert(statusPattern{"mysql/*"}.matchString("wordpress/0"), Equals, false)
+	c.Assert(statusPattern{"mysql"}.matchString("mysql"), Equals, true)
+
+	// CIDR notation against an address, falling back to verbatim matching
+	// for non-CIDR patterns.
+	c.Assert(statusPattern{"10.0.0.0/24"}.matchAddress("10.0.0.5"), Equals, true)
+	c.Assert(statusPattern{"10.0.0.0/24"}.matchAddress("10.1.0.5"), Equals, false)
+	c.Assert(statusPattern{"dummyenv-0.dns"}.matchAddress("dummyenv-0.dns"), Equals, true)
+
+	// Reserved agent-state keywords only match the keyword itself, never
+	// an arbitrary name that happens to equal one.
+	c.Assert(statusPattern{"error"}.matchAgentState("error"), Equals, true)
+	c.Assert(statusPattern{"error"}.matchAgentState("started"), Equals, false)
+	c.Assert(statusPattern{"mysql/0"}.matchAgentState("mysql/0"), Equals, false)
+}
+
+func (s *StatusSuite) TestProcessRelations(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+
+	addCharm{"mysql"}.step(c, ctx)
+	addCharm{"wordpress"}.step(c, ctx)
+	addCharm{"riak"}.step(c, ctx)
+	addService{"mysql", "mysql"}.step(c, ctx)
+	addService{"wordpress", "wordpress"}.step(c, ctx)
+	addService{"riak", "riak"}.step(c, ctx)
+	addRelation{[]string{"wordpress:db", "mysql:db"}}.step(c, ctx)
+	addRelation{[]string{"riak:ring"}}.step(c, ctx)
+
+	mysql, err := ctx.st.Service("mysql")
+	c.Assert(err, IsNil)
+	relations, err := mysql.Relations()
+	c.Assert(err, IsNil)
+	c.Assert(relations, HasLen, 1)
+	result, err := processRelations(mysql, relations)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string][]string{"db": {"wordpress"}})
+
+	riak, err := ctx.st.Service("riak")
+	c.Assert(err, IsNil)
+	relations, err = riak.Relations()
+	c.Assert(err, IsNil)
+	c.Assert(relations, HasLen, 1)
+
+	// A peer relation's only endpoint belongs to the service itself, so
+	// the result is keyed by that endpoint's name, with no related
+	// services.
+	result, err = processRelations(riak, relations)
+	c.Assert(err, IsNil)
+	c.Assert(result, DeepEquals, map[string][]string{"ring": nil})
+}
+
+func (s *StatusSuite) TestMachineStatusHardwareAndAddresses(c *C) {
+	m := MachineStatus{
+		AgentState: "started",
+		DNSName:    "dummyenv-0.dns",
+		InstanceId: "dummyenv-0",
+		Hardware:   "arch=amd64 cpu-cores=1 mem=1740M",
+		Addresses: []AddressStatus{
+			{Value: "10.0.0.1", Type: "ipv4", Scope: "local-cloud"},
+			{Value: "dummyenv-0.dns", Type: "hostname", Scope: "public"},
+		},
+	}
+
+	out, err := goyaml.Marshal(m)
+	c.Assert(err, IsNil)
+	c.Assert(string(out), Matches, "(?s).*hardware: arch=amd64 cpu-cores=1 mem=1740M\n.*")
+	c.Assert(string(out), Matches, "(?s).*value: 10.0.0.1\n.*")
+	c.Assert(string(out), Matches, "(?s).*scope: public\n.*")
+
+	var back MachineStatus
+	err = goyaml.Unmarshal(out, &back)
+	c.Assert(err, IsNil)
+	c.Assert(back, DeepEquals, m)
+}
+
+func (s *StatusSuite) TestApplyDeltasFiltering(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+
+	addMachine{"0", state.JobManageEnviron}.step(c, ctx)
+	startAliveMachine{"0"}.step(c, ctx)
+	setMachineStatus{"0", params.MachineStarted, ""}.step(c, ctx)
+	addMachine{"1", state.JobHostUnits}.step(c, ctx)
+	startAliveMachine{"1"}.step(c, ctx)
+	setMachineStatus{"1", params.MachineError, "Beware the red toys"}.step(c, ctx)
+
+	model := statusResult{
+		Machines: map[int]MachineStatus{
+			0: {AgentState: "started"},
+			1: {AgentState: "started"},
+		},
+		Services: map[string]ServiceStatus{},
+	}
+	patterns := compilePatterns([]string{"started"})
+
+	// A delta naming machine/1, which is actually in error state, must not
+	// re-add it to a model already filtered down to "started" machines.
+	deltas := []state.Delta{{Kind: "machine", Id: "1"}}
+	err := applyDeltas(ctx.conn, ctx.st, &model, deltas, patterns)
+	c.Assert(err, IsNil)
+	_, ok := model.Machines[1]
+	c.Assert(ok, Equals, false)
+
+	// machine/0 still matches "started" and is refreshed in place.
+	deltas = []state.Delta{{Kind: "machine", Id: "0"}}
+	err = applyDeltas(ctx.conn, ctx.st, &model, deltas, patterns)
+	c.Assert(err, IsNil)
+	m, ok := model.Machines[0]
+	c.Assert(ok, Equals, true)
+	c.Assert(m.AgentState, Equals, "started")
+}
+
+func (s *StatusSuite) TestApplyDeltasServiceFiltering(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+
+	addCharm{"dummy"}.step(c, ctx)
+	addService{"dummy-service", "dummy"}.step(c, ctx)
+	addService{"other-service", "dummy"}.step(c, ctx)
+
+	model := statusResult{
+		Machines: map[int]MachineStatus{},
+		Services: map[string]ServiceStatus{
+			"dummy-service": {Charm: "local:series/dummy-1"},
+			"other-service": {Charm: "local:series/dummy-1"},
+		},
+	}
+	patterns := compilePatterns([]string{"dummy-service"})
+
+	// A delta naming other-service, which does not match the pattern,
+	// must not be re-added to the filtered model.
+	deltas := []state.Delta{{Kind: "service", Id: "other-service"}}
+	err := applyDeltas(ctx.conn, ctx.st, &model, deltas, patterns)
+	c.Assert(err, IsNil)
+	_, ok := model.Services["other-service"]
+	c.Assert(ok, Equals, false)
+
+	// dummy-service still matches and is refreshed in place.
+	deltas = []state.Delta{{Kind: "service", Id: "dummy-service"}}
+	err = applyDeltas(ctx.conn, ctx.st, &model, deltas, patterns)
+	c.Assert(err, IsNil)
+	_, ok = model.Services["dummy-service"]
+	c.Assert(ok, Equals, true)
+}
+
+func (s *StatusSuite) TestApplyDeltasUnitRemoved(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+
+	addMachine{"0", state.JobManageEnviron}.step(c, ctx)
+	addMachine{"1", state.JobHostUnits}.step(c, ctx)
+	startAliveMachine{"1"}.step(c, ctx)
+	setMachineStatus{"1", params.MachineStarted, ""}.step(c, ctx)
+	addCharm{"dummy"}.step(c, ctx)
+	addService{"dummy-service", "dummy"}.step(c, ctx)
+	addUnit{"dummy-service", "1"}.step(c, ctx)
+	setUnitStatus{"dummy-service/0", params.UnitStarted, ""}.step(c, ctx)
+
+	model, err := (&StatusCommand{}).buildStatus(ctx.conn, ctx.st)
+	c.Assert(err, IsNil)
+	_, ok := model.Services["dummy-service"].Units["dummy-service/0"]
+	c.Assert(ok, Equals, true)
+
+	// A removed-unit delta must prune the unit from its service's Units
+	// map without re-fetching it from state -- it no longer exists there
+	// -- and without returning an error that would otherwise kill the
+	// whole watch/follow stream.
+	deltas := []state.Delta{{Kind: "unit", Id: "dummy-service/0", Removed: true}}
+	err = applyDeltas(ctx.conn, ctx.st, &model, deltas, nil)
+	c.Assert(err, IsNil)
+	_, ok = model.Services["dummy-service"].Units["dummy-service/0"]
+	c.Assert(ok, Equals, false)
+}
+
+// runWatch runs the status command with args until it exits (via
+// --timeout or an interrupt) or the given overall deadline passes,
+// returning its exit code and captured stdout.
+func runWatch(c *C, deadline time.Duration, args ...string) (code int, stdout string) {
+	tctx := coretesting.Context(c)
+	done := make(chan int, 1)
+	go func() {
+		done <- cmd.Main(&StatusCommand{}, tctx, args)
+	}()
+	select {
+	case code = <-done:
+	case <-time.After(deadline):
+		c.Fatalf("status --watch/--follow did not exit within %s", deadline)
+	}
+	return code, tctx.Stdout.(*bytes.Buffer).String()
+}
+
+func (s *StatusSuite) TestWatchTickerRedrawsYaml(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+	addMachine{"0", state.JobManageEnviron}.step(c, ctx)
+
+	code, out := runWatch(c, 5*time.Second,
+		"--format", "yaml", "--watch", "15ms", "--timeout", "80ms")
+	c.Assert(code, Equals, 0)
+
+	// The ticker should have forced at least one redraw in addition to
+	// the initial frame, each separated by the yaml "---" document marker.
+	c.Assert(strings.Count(out, "---\n") > 1, Equals, true)
+}
+
+func (s *StatusSuite) TestWatchTickerRedrawsTabular(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+	addMachine{"0", state.JobManageEnviron}.step(c, ctx)
+
+	code, out := runWatch(c, 5*time.Second,
+		"--format", "tabular", "--watch", "15ms", "--timeout", "80ms")
+	c.Assert(code, Equals, 0)
+
+	// Every tabular redraw (including the first frame) clears the screen
+	// before writing the table.
+	c.Assert(strings.Count(out, "\033[2J\033[H") > 1, Equals, true)
+	c.Assert(out, Matches, "(?s).*\\[Machines\\].*")
+}
+
+func (s *StatusSuite) TestWatchTimeoutStopsTheLoop(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+	addMachine{"0", state.JobManageEnviron}.step(c, ctx)
+
+	code, _ := runWatch(c, 5*time.Second,
+		"--format", "yaml", "--follow", "--timeout", "30ms")
+	c.Assert(code, Equals, 0)
+}
+
+func (s *StatusSuite) TestWatchSIGINTFlushesFinalFrame(c *C) {
+	ctx := s.newContext()
+	defer s.resetContext(c, ctx)
+	addMachine{"0", state.JobManageEnviron}.step(c, ctx)
+
+	tctx := coretesting.Context(c)
+	done := make(chan int, 1)
+	go func() {
+		done <- cmd.Main(&StatusCommand{}, tctx, []string{
+			"--format", "yaml", "--follow",
+		})
+	}()
+
+	// Give the loop time to render its initial frame and start watching,
+	// then interrupt it as a user hitting Ctrl-C would.
+	time.Sleep(50 * time.Millisecond)
+	proc, err := os.FindProcess(os.Getpid())
+	c.Assert(err, IsNil)
+	c.Assert(proc.Signal(os.Interrupt), IsNil)
+
+	var code int
+	select {
+	case code = <-done:
+	case <-time.After(5 * time.Second):
+		c.Fatalf("watchLoop did not exit after SIGINT")
+	}
+	c.Assert(code, Equals, 0)
+
+	// The initial frame plus the interrupt-triggered final flush should
+	// both have been written.
+	out := tctx.Stdout.(*bytes.Buffer).String()
+	c.Assert(strings.Count(out, "---\n") >= 2, Equals, true)
+}
+
 func (s *StatusSuite) TestStatusAllFormats(c *C) {
 	for i, t := range statusTests {
 		c.Log("test %d: %s", i, t.summary)